@@ -0,0 +1,93 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+const defaultBackend = "xdotool"
+
+// Backend sends a parsed shortcut to whatever receives synthetic key events.
+type Backend interface {
+	Send(shortcut string) error
+	Close() error
+}
+
+// newBackend builds the backend selected by the `backend:` config line (or
+// defaultBackend when none was given).
+func newBackend(name string) (Backend, error) {
+	switch name {
+	case "", defaultBackend:
+		return xdotoolBackend{}, nil
+	case "ydotool":
+		return ydotoolBackend{}, nil
+	case "uinput":
+		return newUinputBackend()
+	default:
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+}
+
+// xdotoolBackend forks `xdotool` for every keypress, it only works under X11.
+type xdotoolBackend struct{}
+
+func (xdotoolBackend) Send(shortcut string) error {
+	return exec.Command("xdotool", "key", shortcut).Run()
+}
+
+func (xdotoolBackend) Close() error {
+	return nil
+}
+
+// ydotoolBackend forks `ydotool`, it works under Wayland given `ydotoold` is running.
+type ydotoolBackend struct{}
+
+func (ydotoolBackend) Send(shortcut string) error {
+	args, errArgs := ydotoolKeyArgs(shortcut)
+	if errArgs != nil {
+		return errArgs
+	}
+	return exec.Command("ydotool", append([]string{"key"}, args...)...).Run()
+}
+
+// ydotoolKeyArgs turns a shortcut into the `keycode:state` pairs ydotool's
+// `key` subcommand expects, pressing modifiers, tapping the key and releasing
+// everything in reverse order, the same sequence uinputBackend.Send emits.
+// Unlike xdotool, ydotool has no notion of `+`-joined modifier syntax or XKB
+// names, so the shortcut must be translated via parseShortcut first.
+func ydotoolKeyArgs(shortcut string) ([]string, error) {
+	keys, errParse := parseShortcut(shortcut)
+	if errParse != nil {
+		return nil, errParse
+	}
+	args := make([]string, 0, 2*len(keys.modifiers)+2)
+	for _, code := range keys.modifiers {
+		args = append(args, fmt.Sprintf("%d:1", code))
+	}
+	args = append(args, fmt.Sprintf("%d:1", keys.key), fmt.Sprintf("%d:0", keys.key))
+	for i := len(keys.modifiers) - 1; i >= 0; i-- {
+		args = append(args, fmt.Sprintf("%d:0", keys.modifiers[i]))
+	}
+	return args, nil
+}
+
+func (ydotoolBackend) Close() error {
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestYdotoolKeyArgsModifiersAndKey(t *testing.T) {
+	got, errArgs := ydotoolKeyArgs("ctrl+alt+t")
+	if errArgs != nil {
+		t.Fatalf("ydotoolKeyArgs returned error: %v", errArgs)
+	}
+	want := []string{
+		fmt.Sprintf("%d:1", keyLeftCtrl),
+		fmt.Sprintf("%d:1", keyLeftAlt),
+		fmt.Sprintf("%d:1", keyT),
+		fmt.Sprintf("%d:0", keyT),
+		fmt.Sprintf("%d:0", keyLeftAlt),
+		fmt.Sprintf("%d:0", keyLeftCtrl),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("args[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestYdotoolKeyArgsBareKey(t *testing.T) {
+	got, errArgs := ydotoolKeyArgs("XF86AudioRaiseVolume")
+	if errArgs != nil {
+		t.Fatalf("ydotoolKeyArgs returned error: %v", errArgs)
+	}
+	want := []string{
+		fmt.Sprintf("%d:1", keyVolumeUp),
+		fmt.Sprintf("%d:0", keyVolumeUp),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("args[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestYdotoolKeyArgsUnknownKey(t *testing.T) {
+	if _, errArgs := ydotoolKeyArgs("ctrl+bogus"); errArgs == nil {
+		t.Fatal("expected an error for an unknown key")
+	}
+}
+
+func TestNewBackendUnknown(t *testing.T) {
+	if _, errBackend := newBackend("bogus"); errBackend == nil {
+		t.Fatal("expected an error for an unknown backend name")
+	}
+}
@@ -0,0 +1,45 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"time"
+)
+
+const commandTimeout = 10 * time.Second
+
+// runCommand runs cmd through the shell, bounded by commandTimeout, and logs
+// whatever it printed. This is what a `!`-prefixed config value dispatches
+// to, turning tvctl into a general-purpose IR-to-anything bridge.
+func runCommand(ctx context.Context, cmd string) error {
+	cctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	out, errRun := exec.CommandContext(cctx, "sh", "-c", cmd).CombinedOutput()
+	if len(out) > 0 {
+		slog.Info("command output", "command", cmd, "output", string(out))
+	}
+	if errRun != nil {
+		return fmt.Errorf("command %q failed: %w", cmd, errRun)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRunCommandSuccess(t *testing.T) {
+	if errRun := runCommand(context.Background(), "exit 0"); errRun != nil {
+		t.Fatalf("runCommand returned error: %v", errRun)
+	}
+}
+
+func TestRunCommandFailure(t *testing.T) {
+	if errRun := runCommand(context.Background(), "exit 1"); errRun == nil {
+		t.Fatal("expected an error for a failing command")
+	}
+}
+
+func TestRunCommandLogsOutput(t *testing.T) {
+	var buf bytes.Buffer
+	orig := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(orig)
+
+	if errRun := runCommand(context.Background(), "echo hello-tvctl"); errRun != nil {
+		t.Fatalf("runCommand returned error: %v", errRun)
+	}
+	if !strings.Contains(buf.String(), "hello-tvctl") {
+		t.Errorf("expected command output to be logged, got %q", buf.String())
+	}
+}
+
+func TestRunCommandRespectsParentCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if errRun := runCommand(ctx, "sleep 5"); errRun == nil {
+		t.Fatal("expected an error when the parent context is already cancelled")
+	}
+}
@@ -0,0 +1,251 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/vimusov/tvctl/sdnotify"
+)
+
+// actionKind tells processCommands whether a keyDesc's action is a keyboard
+// shortcut to send through the Backend or a shell command to run directly.
+type actionKind int
+
+const (
+	actionShortcut actionKind = iota
+	actionCommand
+)
+
+type keyDesc struct {
+	kind    actionKind
+	action  string
+	comment string
+}
+
+// Config holds the parsed tvctl.conf and keeps the key table swappable so
+// Watch can apply edits without restarting the daemon.
+type Config struct {
+	path        string
+	source      sourceSpec
+	backendName string
+	table       atomic.Pointer[map[int]keyDesc]
+}
+
+// newConfig loads ~/.config/tvctl.conf for the first time.
+func newConfig() (*Config, error) {
+	homeDir, errHomeDir := os.UserHomeDir()
+	if errHomeDir != nil {
+		return nil, fmt.Errorf("unable to get home directory: %w", errHomeDir)
+	}
+	path := filepath.Join(homeDir, ".config", "tvctl.conf")
+	source, backend, table, errParse := parseConfigFile(path)
+	if errParse != nil {
+		return nil, errParse
+	}
+	cfg := &Config{path: path, source: source, backendName: backend}
+	cfg.table.Store(&table)
+	return cfg, nil
+}
+
+// Table returns the key table currently in effect.
+func (c *Config) Table() map[int]keyDesc {
+	return *c.table.Load()
+}
+
+// reload re-parses the config file and swaps in the key table. The source
+// and backend are only meaningful at startup (main creates the Source and
+// Backend once from them), so a reload leaves c.source/c.backendName alone
+// instead of racing them against the concurrent reads in processCommands.
+func (c *Config) reload() error {
+	_, _, table, errParse := parseConfigFile(c.path)
+	if errParse != nil {
+		return errParse
+	}
+	c.table.Store(&table)
+	return nil
+}
+
+// Watch observes the config file and hot-reloads the key table on every
+// write. A parse error is logged and the previous table is kept, so a typo
+// never takes the daemon down. notifier is told RELOADING=1/READY=1 around
+// every attempt, so systemd doesn't consider the daemon hung mid-reload.
+func (c *Config) Watch(ctx context.Context, notifier *sdnotify.Notifier) {
+	watcher, errWatcher := fsnotify.NewWatcher()
+	if errWatcher != nil {
+		slog.Error("unable to watch config", "error", errWatcher)
+		return
+	}
+	defer func() {
+		if errClose := watcher.Close(); errClose != nil {
+			slog.Warn("unable to close watcher", "error", errClose)
+		}
+	}()
+
+	if errAdd := watcher.Add(filepath.Dir(c.path)); errAdd != nil {
+		slog.Error("unable to watch config directory", "path", c.path, "error", errAdd)
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Name != c.path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if errReloading := notifier.Reloading(); errReloading != nil {
+				slog.Warn("unable to notify reloading", "error", errReloading)
+			}
+			if errReload := c.reload(); errReload != nil {
+				slog.Warn("unable to reload config, keeping previous", "path", c.path, "error", errReload)
+			} else {
+				slog.Info("config reloaded", "path", c.path)
+			}
+			if errReady := notifier.Ready(); errReady != nil {
+				slog.Warn("unable to notify ready", "error", errReady)
+			}
+		case errWatch, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			slog.Warn("watcher error", "error", errWatch)
+		}
+	}
+}
+
+// parseConfigFile is the actual tvctl.conf grammar: an optional `backend:`
+// line, a `serial:`/`evdev:`/`socket:` (or bare `/dev/...`, kept for
+// compatibility) source line and any number of `key: shortcut # comment`
+// lines, where the value is either a keyboard shortcut or, prefixed with
+// `!`, an arbitrary shell command (e.g. `42: !mpc toggle  # play/pause`).
+func parseConfigFile(cfgPath string) (sourceSpec, string, map[int]keyDesc, error) {
+	content, errRead := os.ReadFile(cfgPath)
+	if errRead != nil {
+		return sourceSpec{}, "", nil, fmt.Errorf("unable to load config: %w", errRead)
+	}
+
+	source := sourceSpec{}
+	haveSource := false
+	backend := ""
+	table := map[int]keyDesc{}
+	for index, rawLine := range strings.Split(string(content), "\n") {
+		line := strings.TrimSpace(rawLine)
+		lineno := index + 1
+		if len(line) == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rest, found := strings.CutPrefix(line, "backend:"); found {
+			if backend != "" {
+				return sourceSpec{}, "", nil, fmt.Errorf("backend is already defined as %q, error at line %d in %q", backend, lineno, cfgPath)
+			}
+			backend = strings.TrimSpace(rest)
+			continue
+		}
+		if kind, rest, found := cutSourceKind(line); found {
+			if haveSource {
+				return sourceSpec{}, "", nil, fmt.Errorf("source is already defined as %q, error at line %d in %q", source.addr, lineno, cfgPath)
+			}
+			parsed, errParse := parseSourceLine(kind, rest)
+			if errParse != nil {
+				return sourceSpec{}, "", nil, fmt.Errorf("wrong source value %q in %q at line %d: %w", line, cfgPath, lineno, errParse)
+			}
+			source = parsed
+			haveSource = true
+			continue
+		}
+		keyPart, valPart, found := strings.Cut(line, ":")
+		if !found {
+			return sourceSpec{}, "", nil, fmt.Errorf("invalid config, no separator ':' in %q at line %d", cfgPath, lineno)
+		}
+		key, errConv := strconv.Atoi(strings.TrimSpace(keyPart))
+		if errConv != nil {
+			return sourceSpec{}, "", nil, fmt.Errorf("wrong integer value %q in %q at line %d", keyPart, cfgPath, lineno)
+		}
+		rawAction, comment, _ := strings.Cut(valPart, "#")
+		action := strings.TrimSpace(rawAction)
+		kind := actionShortcut
+		if rest, found := strings.CutPrefix(action, "!"); found {
+			kind = actionCommand
+			action = strings.TrimSpace(rest)
+		}
+		table[key] = keyDesc{kind: kind, action: action, comment: strings.TrimSpace(comment)}
+	}
+	return source, backend, table, nil
+}
+
+// cutSourceKind recognises a `serial:`/`evdev:`/`socket:` line, or a bare
+// `/dev/...` device path kept for backwards compatibility with older configs
+// (treated as an implicit `serial:`).
+func cutSourceKind(line string) (string, string, bool) {
+	for _, kind := range []string{"serial", "evdev", "socket"} {
+		if rest, found := strings.CutPrefix(line, kind+":"); found {
+			return kind, rest, true
+		}
+	}
+	if strings.HasPrefix(line, "/dev/") {
+		return "serial", line, true
+	}
+	return "", "", false
+}
+
+// parseSourceLine turns the part after the `kind:` prefix into a sourceSpec,
+// e.g. "/dev/ttyUSB0 9600" for serial or "/dev/input/by-id/..." for evdev.
+func parseSourceLine(kind, rest string) (sourceSpec, error) {
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return sourceSpec{}, fmt.Errorf("missing device path")
+	}
+	addr := fields[0]
+	spec := sourceSpec{kind: kind, addr: addr}
+
+	if kind == "serial" {
+		info, infoErr := os.Stat(addr)
+		if infoErr != nil {
+			return sourceSpec{}, infoErr
+		}
+		if info.Mode()&fs.ModeCharDevice == 0 {
+			return sourceSpec{}, fmt.Errorf("%q is not a character device", addr)
+		}
+		if len(fields) > 1 {
+			baud, errConv := strconv.Atoi(fields[1])
+			if errConv != nil {
+				return sourceSpec{}, fmt.Errorf("wrong baud rate %q: %w", fields[1], errConv)
+			}
+			spec.baud = uint32(baud)
+		}
+	}
+	return spec, nil
+}
@@ -0,0 +1,123 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tvctl.conf")
+	if errWrite := os.WriteFile(path, []byte(content), 0o644); errWrite != nil {
+		t.Fatalf("unable to write test config: %v", errWrite)
+	}
+	return path
+}
+
+func TestParseConfigFileBackendAndEvdevSource(t *testing.T) {
+	path := writeTestConfig(t, "backend: uinput\nevdev: /dev/null\n42: ctrl+alt+t\n")
+	source, backend, table, errParse := parseConfigFile(path)
+	if errParse != nil {
+		t.Fatalf("parseConfigFile returned error: %v", errParse)
+	}
+	if backend != "uinput" {
+		t.Errorf("backend = %q, want %q", backend, "uinput")
+	}
+	if source.kind != "evdev" || source.addr != "/dev/null" {
+		t.Errorf("source = %+v, want kind=evdev addr=/dev/null", source)
+	}
+	key, found := table[42]
+	if !found || key.kind != actionShortcut || key.action != "ctrl+alt+t" {
+		t.Errorf("table[42] = %+v, found=%v, want shortcut ctrl+alt+t", key, found)
+	}
+}
+
+func TestParseConfigFileSerialWithBaud(t *testing.T) {
+	path := writeTestConfig(t, "serial: /dev/null 19200\n1: a\n")
+	source, _, _, errParse := parseConfigFile(path)
+	if errParse != nil {
+		t.Fatalf("parseConfigFile returned error: %v", errParse)
+	}
+	if source.kind != "serial" || source.addr != "/dev/null" || source.baud != 19200 {
+		t.Errorf("source = %+v, want kind=serial addr=/dev/null baud=19200", source)
+	}
+}
+
+func TestParseConfigFileBareDevPath(t *testing.T) {
+	path := writeTestConfig(t, "/dev/null\n1: a\n")
+	source, _, _, errParse := parseConfigFile(path)
+	if errParse != nil {
+		t.Fatalf("parseConfigFile returned error: %v", errParse)
+	}
+	if source.kind != "serial" || source.addr != "/dev/null" {
+		t.Errorf("source = %+v, want the bare path treated as serial", source)
+	}
+}
+
+func TestParseConfigFileSocketSource(t *testing.T) {
+	path := writeTestConfig(t, "socket: /run/lircd\n1: a\n")
+	source, _, _, errParse := parseConfigFile(path)
+	if errParse != nil {
+		t.Fatalf("parseConfigFile returned error: %v", errParse)
+	}
+	if source.kind != "socket" || source.addr != "/run/lircd" {
+		t.Errorf("source = %+v, want kind=socket addr=/run/lircd", source)
+	}
+}
+
+func TestParseConfigFileDuplicateSource(t *testing.T) {
+	path := writeTestConfig(t, "serial: /dev/null\nevdev: /dev/null\n")
+	if _, _, _, errParse := parseConfigFile(path); errParse == nil {
+		t.Fatal("expected an error for a duplicate source line")
+	}
+}
+
+func TestParseConfigFileDuplicateBackend(t *testing.T) {
+	path := writeTestConfig(t, "backend: uinput\nbackend: xdotool\n")
+	if _, _, _, errParse := parseConfigFile(path); errParse == nil {
+		t.Fatal("expected an error for a duplicate backend line")
+	}
+}
+
+func TestParseConfigFileCommandAction(t *testing.T) {
+	path := writeTestConfig(t, "42: !mpc toggle  # play/pause\n")
+	_, _, table, errParse := parseConfigFile(path)
+	if errParse != nil {
+		t.Fatalf("parseConfigFile returned error: %v", errParse)
+	}
+	key := table[42]
+	if key.kind != actionCommand {
+		t.Errorf("kind = %v, want actionCommand", key.kind)
+	}
+	if key.action != "mpc toggle" {
+		t.Errorf("action = %q, want %q", key.action, "mpc toggle")
+	}
+	if key.comment != "play/pause" {
+		t.Errorf("comment = %q, want %q", key.comment, "play/pause")
+	}
+}
+
+func TestParseConfigFileMissingSeparator(t *testing.T) {
+	path := writeTestConfig(t, "not a valid line\n")
+	if _, _, _, errParse := parseConfigFile(path); errParse == nil {
+		t.Fatal("expected an error for a line with no ':' separator")
+	}
+}
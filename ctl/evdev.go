@@ -0,0 +1,108 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// eviocgrab, from <linux/input.h>: _IOW('E', 0x90, int).
+const eviocgrab = 0x40044590
+
+// evdevSource reads key codes straight off a /dev/input/eventN node, useful
+// for cheap USB IR/RF receivers that show up as a plain input device. If the
+// device disappears (e.g. the USB receiver is unplugged) it reopens it with
+// an exponential backoff instead of busy-looping on the error.
+type evdevSource struct {
+	name string
+	file *os.File
+}
+
+// newEvdevSource opens the event device and grabs it exclusively, so the
+// codes it reports don't also leak into whatever display server is running.
+func newEvdevSource(name string) (Source, error) {
+	file, errOpen := openEvdevDevice(name)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	return &evdevSource{name: name, file: file}, nil
+}
+
+func openEvdevDevice(name string) (*os.File, error) {
+	rawFD, errOpen := unix.Open(name, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if errOpen != nil {
+		return nil, fmt.Errorf("unable open %q: %w", name, errOpen)
+	}
+	file := os.NewFile(uintptr(rawFD), name)
+
+	if errGrab := unix.IoctlSetInt(int(file.Fd()), eviocgrab, 1); errGrab != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("unable to grab %q: %w", name, errGrab)
+	}
+	return file, nil
+}
+
+// ReadCode blocks until a key-press event arrives and returns its code,
+// key-release and non-key events (EV_SYN, EV_MSC, ...) are skipped. A
+// signal interrupting the read is retried rather than treated as an error.
+func (s *evdevSource) ReadCode(ctx context.Context) (int, error) {
+	for {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return 0, errCtx
+		}
+		var event inputEvent
+		errRead := binary.Read(s.file, binary.LittleEndian, &event)
+		if errRead != nil {
+			if errors.Is(errRead, unix.EINTR) {
+				continue
+			}
+			if errReopen := s.reopen(ctx); errReopen != nil {
+				return 0, errReopen
+			}
+			continue
+		}
+		if event.Type != evKey || event.Value != 1 {
+			continue
+		}
+		return int(event.Code), nil
+	}
+}
+
+// reopen closes the stale file and keeps retrying open at an exponential
+// backoff until it succeeds or ctx is done.
+func (s *evdevSource) reopen(ctx context.Context) error {
+	_ = s.file.Close()
+	return retryWithBackoff(ctx, "evdev device", s.name, func() error {
+		file, errOpen := openEvdevDevice(s.name)
+		if errOpen != nil {
+			return errOpen
+		}
+		s.file = file
+		return nil
+	})
+}
+
+func (s *evdevSource) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,184 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Linux input-event codes from <linux/input-event-codes.h>. x/sys/unix does
+// not export these (they're application-level, not syscall numbers), so
+// they're spelt out the same way every uinput library has to.
+const (
+	evSyn = 0x00
+	evKey = 0x01
+
+	synReport = 0
+
+	keyEsc       = 1
+	key1         = 2
+	key2         = 3
+	key3         = 4
+	key4         = 5
+	key5         = 6
+	key6         = 7
+	key7         = 8
+	key8         = 9
+	key9         = 10
+	key0         = 11
+	keyBackspace = 14
+	keyTab       = 15
+	keyQ         = 16
+	keyW         = 17
+	keyE         = 18
+	keyR         = 19
+	keyT         = 20
+	keyY         = 21
+	keyU         = 22
+	keyI         = 23
+	keyO         = 24
+	keyP         = 25
+	keyEnter     = 28
+	keyLeftCtrl  = 29
+	keyA         = 30
+	keyS         = 31
+	keyD         = 32
+	keyF         = 33
+	keyG         = 34
+	keyH         = 35
+	keyJ         = 36
+	keyK         = 37
+	keyL         = 38
+	keyLeftShift = 42
+	keyZ         = 44
+	keyX         = 45
+	keyC         = 46
+	keyV         = 47
+	keyB         = 48
+	keyN         = 49
+	keyM         = 50
+	keyLeftAlt   = 56
+	keySpace     = 57
+
+	keyHome     = 102
+	keyUp       = 103
+	keyPageUp   = 104
+	keyLeft     = 105
+	keyRight    = 106
+	keyEnd      = 107
+	keyDown     = 108
+	keyPageDown = 109
+	keyDelete   = 111
+
+	keyMute       = 113
+	keyVolumeDown = 114
+	keyVolumeUp   = 115
+
+	keyNextSong     = 163
+	keyPlayPause    = 164
+	keyPreviousSong = 165
+	keyStopCD       = 166
+	keyHomePage     = 172
+
+	keyLeftMeta = 125
+)
+
+// modifierKeys are keysym names that press-and-hold rather than tap.
+var modifierKeys = map[string]uint16{
+	"ctrl":  keyLeftCtrl,
+	"shift": keyLeftShift,
+	"alt":   keyLeftAlt,
+	"super": keyLeftMeta,
+	"meta":  keyLeftMeta,
+	"win":   keyLeftMeta,
+}
+
+// keysymCodes maps XKB-style names (and the common XF86 media keys) to Linux
+// input-event codes. Only the names tvctl configs are likely to reference are
+// listed, extend as needed.
+var keysymCodes = map[string]uint16{
+	"a": keyA, "b": keyB, "c": keyC, "d": keyD,
+	"e": keyE, "f": keyF, "g": keyG, "h": keyH,
+	"i": keyI, "j": keyJ, "k": keyK, "l": keyL,
+	"m": keyM, "n": keyN, "o": keyO, "p": keyP,
+	"q": keyQ, "r": keyR, "s": keyS, "t": keyT,
+	"u": keyU, "v": keyV, "w": keyW, "x": keyX,
+	"y": keyY, "z": keyZ,
+	"0": key0, "1": key1, "2": key2, "3": key3,
+	"4": key4, "5": key5, "6": key6, "7": key7,
+	"8": key8, "9": key9,
+	"space":     keySpace,
+	"enter":     keyEnter,
+	"return":    keyEnter,
+	"tab":       keyTab,
+	"esc":       keyEsc,
+	"escape":    keyEsc,
+	"backspace": keyBackspace,
+	"delete":    keyDelete,
+	"home":      keyHome,
+	"end":       keyEnd,
+	"pageup":    keyPageUp,
+	"pagedown":  keyPageDown,
+	"up":        keyUp,
+	"down":      keyDown,
+	"left":      keyLeft,
+	"right":     keyRight,
+
+	"xf86audioraisevolume": keyVolumeUp,
+	"xf86audiolowervolume": keyVolumeDown,
+	"xf86audiomute":        keyMute,
+	"xf86audioplay":        keyPlayPause,
+	"xf86audiostop":        keyStopCD,
+	"xf86audionext":        keyNextSong,
+	"xf86audioprev":        keyPreviousSong,
+	"xf86homepage":         keyHomePage,
+}
+
+// parsedShortcut is a shortcut split into the modifiers to hold and the key to tap.
+type parsedShortcut struct {
+	modifiers []uint16
+	key       uint16
+}
+
+// parseShortcut turns a config shortcut like "ctrl+alt+t" or "XF86AudioRaiseVolume"
+// into Linux KEY_* codes, modifiers first, in the order they were given.
+func parseShortcut(shortcut string) (parsedShortcut, error) {
+	var result parsedShortcut
+	parts := strings.Split(shortcut, "+")
+	for i, rawPart := range parts {
+		name := strings.ToLower(strings.TrimSpace(rawPart))
+		last := i == len(parts)-1
+		if !last {
+			code, found := modifierKeys[name]
+			if !found {
+				return parsedShortcut{}, fmt.Errorf("unknown modifier %q in shortcut %q", rawPart, shortcut)
+			}
+			result.modifiers = append(result.modifiers, code)
+			continue
+		}
+		code, found := keysymCodes[name]
+		if !found {
+			if code, found = modifierKeys[name]; !found {
+				return parsedShortcut{}, fmt.Errorf("unknown key %q in shortcut %q", rawPart, shortcut)
+			}
+		}
+		result.key = code
+	}
+	return result, nil
+}
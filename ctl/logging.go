@@ -0,0 +1,40 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"log/slog"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// newLogger picks a human-readable handler for an interactive terminal and a
+// JSON one otherwise, so journald parses the fields cleanly when tvctl runs
+// as a systemd service with stderr connected to a pipe.
+func newLogger() *slog.Logger {
+	if isTerminal(int(os.Stderr.Fd())) {
+		return slog.New(slog.NewTextHandler(os.Stderr, nil))
+	}
+	return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+}
+
+func isTerminal(fd int) bool {
+	_, errTio := unix.IoctlGetTermios(fd, unix.TCGETS)
+	return errTio == nil
+}
@@ -0,0 +1,143 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultPortSpeed     uint32        = 9600
+	reopenInitialBackoff               = time.Second
+	reopenMaxBackoff     time.Duration = 30 * time.Second
+)
+
+// serialSource reads newline-terminated decimal codes off an Arduino's
+// character device, the original tvctl input method. If the device
+// disappears (common with USB Arduinos on suspend/resume) it reopens it
+// with an exponential backoff instead of dying.
+type serialSource struct {
+	name   string
+	baud   uint32
+	portFD int
+}
+
+// newSerialSource opens the character device and configures the termios the
+// same way tvctl always has, at the given baud rate (defaultPortSpeed if 0).
+func newSerialSource(name string, baud uint32) (Source, error) {
+	if baud == 0 {
+		baud = defaultPortSpeed
+	}
+	portFD, errOpen := openSerialPort(name, baud)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	return &serialSource{name: name, baud: baud, portFD: portFD}, nil
+}
+
+func openSerialPort(name string, baud uint32) (int, error) {
+	portFD, errOpen := unix.Open(name, unix.O_RDONLY|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if errOpen != nil {
+		return 0, fmt.Errorf("unable open port: %w", errOpen)
+	}
+
+	tios := unix.Termios{}
+	tios.Cflag |= unix.CREAD | unix.CLOCAL | unix.BOTHER | unix.CS8
+	tios.Ispeed = baud
+	tios.Ospeed = baud
+	tios.Iflag |= unix.INPCK
+	tios.Cc[unix.VMIN] = 1
+	tios.Cc[unix.VTIME] = 0
+
+	if errTio := unix.IoctlSetTermios(portFD, unix.TCSETS2, &tios); errTio != nil {
+		_ = unix.Close(portFD)
+		return 0, fmt.Errorf("unable set flags: %w", errTio)
+	}
+	return portFD, nil
+}
+
+func (s *serialSource) ReadCode(ctx context.Context) (int, error) {
+	for {
+		buf := make([]byte, 8)
+		size, errRead := unix.Read(s.portFD, buf)
+		if errRead != nil {
+			if errors.Is(errRead, unix.EINTR) || errors.Is(errRead, unix.EAGAIN) {
+				continue
+			}
+			if errReopen := s.reopen(ctx); errReopen != nil {
+				return 0, errReopen
+			}
+			continue
+		}
+		data := string(buf[:size])
+		value, errConv := strconv.Atoi(strings.TrimSpace(data))
+		if errConv != nil {
+			return 0, fmt.Errorf("invalid code value %q: %w", data, errConv)
+		}
+		return value, nil
+	}
+}
+
+// reopen closes the stale fd and keeps retrying open at an exponential
+// backoff (capped at reopenMaxBackoff) until it succeeds or ctx is done.
+func (s *serialSource) reopen(ctx context.Context) error {
+	_ = unix.Close(s.portFD)
+	return retryWithBackoff(ctx, "serial port", s.name, func() error {
+		portFD, errOpen := openSerialPort(s.name, s.baud)
+		if errOpen != nil {
+			return errOpen
+		}
+		s.portFD = portFD
+		return nil
+	})
+}
+
+// retryWithBackoff calls open until it succeeds or ctx is done, backing off
+// exponentially (capped at reopenMaxBackoff) in between attempts. what/name
+// are only used for the log messages, e.g. "serial port"/"/dev/ttyUSB0".
+func retryWithBackoff(ctx context.Context, what, name string, open func() error) error {
+	backoff := reopenInitialBackoff
+	for {
+		if errOpen := open(); errOpen == nil {
+			slog.Info("reopened", "what", what, "name", name)
+			return nil
+		} else {
+			slog.Warn("unable to reopen, retrying", "what", what, "name", name, "error", errOpen, "backoff", backoff)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > reopenMaxBackoff {
+			backoff = reopenMaxBackoff
+		}
+	}
+}
+
+func (s *serialSource) Close() error {
+	return unix.Close(s.portFD)
+}
@@ -0,0 +1,93 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// socketSource reads newline-terminated decimal codes off a Unix socket, e.g.
+// a CEC daemon configured to emit a bare code per line (not lircd's own
+// space-separated hex/repeat/button/remote format, which this does not
+// parse). If the peer goes away it reconnects with an exponential backoff
+// instead of busy-looping on the error.
+type socketSource struct {
+	addr   string
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newSocketSource(addr string) (Source, error) {
+	conn, errDial := dialSocket(addr)
+	if errDial != nil {
+		return nil, errDial
+	}
+	return &socketSource{addr: addr, conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func dialSocket(addr string) (net.Conn, error) {
+	conn, errDial := net.Dial("unix", addr)
+	if errDial != nil {
+		return nil, fmt.Errorf("unable to dial %q: %w", addr, errDial)
+	}
+	return conn, nil
+}
+
+func (s *socketSource) ReadCode(ctx context.Context) (int, error) {
+	for {
+		if errCtx := ctx.Err(); errCtx != nil {
+			return 0, errCtx
+		}
+		line, errRead := s.reader.ReadString('\n')
+		if errRead != nil {
+			if errReconnect := s.reconnect(ctx); errReconnect != nil {
+				return 0, errReconnect
+			}
+			continue
+		}
+		value, errConv := strconv.Atoi(strings.TrimSpace(line))
+		if errConv != nil {
+			return 0, fmt.Errorf("invalid code value %q: %w", line, errConv)
+		}
+		return value, nil
+	}
+}
+
+// reconnect closes the stale connection and keeps retrying the dial at an
+// exponential backoff until it succeeds or ctx is done.
+func (s *socketSource) reconnect(ctx context.Context) error {
+	_ = s.conn.Close()
+	return retryWithBackoff(ctx, "socket", s.addr, func() error {
+		conn, errDial := dialSocket(s.addr)
+		if errDial != nil {
+			return errDial
+		}
+		s.conn = conn
+		s.reader = bufio.NewReader(conn)
+		return nil
+	})
+}
+
+func (s *socketSource) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,53 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// sourceSpec is what the config's port line (`serial:`, `evdev:`, `socket:`
+// or a bare `/dev/...`) resolves to.
+type sourceSpec struct {
+	kind string
+	addr string
+	baud uint32
+}
+
+// Source reads raw codes coming from whatever hardware tvctl is bound to.
+// ReadCode takes a context so a source that retries internally (e.g. serial
+// reopening with backoff) can still be interrupted by shutdown.
+type Source interface {
+	ReadCode(ctx context.Context) (int, error)
+	Close() error
+}
+
+// newSource builds the Source selected by the config's port line.
+func newSource(spec sourceSpec) (Source, error) {
+	switch spec.kind {
+	case "serial", "":
+		return newSerialSource(spec.addr, spec.baud)
+	case "evdev":
+		return newEvdevSource(spec.addr)
+	case "socket":
+		return newSocketSource(spec.addr)
+	default:
+		return nil, fmt.Errorf("unknown source %q", spec.kind)
+	}
+}
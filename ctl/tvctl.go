@@ -18,199 +18,177 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"golang.org/x/sys/unix"
-	"io/fs"
-	"log"
-	"net"
+	"log/slog"
 	"os"
-	"os/exec"
 	"os/signal"
-	"path/filepath"
-	"strconv"
-	"strings"
+	"sync"
 	"syscall"
 	"time"
-)
 
-const (
-	portSpeed   uint32 = 9600
-	repeatDelay        = 300 * time.Millisecond
+	"github.com/vimusov/tvctl/sdnotify"
 )
 
-type keyDesc struct {
-	shortcut string
-	comment  string
-}
-
-func openPort(name string) int {
-	portFD, errOpen := unix.Open(name, unix.O_RDONLY|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
-	if errOpen != nil {
-		log.Fatalf("Unable open port: %v.", errOpen)
-	}
-
-	tios := unix.Termios{}
-	tios.Cflag |= unix.CREAD | unix.CLOCAL | unix.BOTHER | unix.CS8
-	tios.Ispeed = portSpeed
-	tios.Ospeed = portSpeed
-	tios.Iflag |= unix.INPCK
-	tios.Cc[unix.VMIN] = 1
-	tios.Cc[unix.VTIME] = 0
-
-	if errTio := unix.IoctlSetTermios(portFD, unix.TCSETS2, &tios); errTio != nil {
-		if errClose := unix.Close(portFD); errClose != nil {
-			log.Fatalf("Unable close port: %v.", errClose)
-		}
-		log.Fatalf("Unable set flags: %v.", errTio)
-	}
-	return portFD
-}
-
-func loadConfig() (string, map[int]keyDesc) {
-	homeDir, errHomeDir := os.UserHomeDir()
-	if errHomeDir != nil {
-		log.Fatalf("Unable to get home directory: %v.", errHomeDir)
-	}
-
-	cfgPath := filepath.Join(homeDir, ".config", "tvctl.conf")
-	content, errRead := os.ReadFile(cfgPath)
-	if errRead != nil {
-		log.Fatalf("Unable to load config: %v.", errRead)
-	}
+const repeatDelay = 300 * time.Millisecond
 
-	port := ""
-	table := map[int]keyDesc{}
-	for index, rawLine := range strings.Split(string(content), "\n") {
-		line := strings.TrimSpace(rawLine)
-		lineno := index + 1
-		if len(line) == 0 {
-			continue
-		}
-		if strings.HasPrefix(line, "#") {
-			continue
-		}
-		if strings.HasPrefix(line, "/dev/") {
-			if port != "" {
-				log.Fatalf("Port is already defined as %q, error at line %d in %q.", port, lineno, cfgPath)
+func showCodes(ctx context.Context, source Source, cfg *Config) {
+	for ctx.Err() == nil {
+		code, errRead := source.ReadCode(ctx)
+		if errRead != nil {
+			if ctx.Err() != nil {
+				return
 			}
-			info, infoErr := os.Stat(line)
-			if infoErr != nil {
-				log.Fatalf("Wrong port value %q in %q, error %v.", line, cfgPath, infoErr)
-			}
-			if info.Mode()&fs.ModeCharDevice == 0 {
-				log.Fatalf("%q is not a valid device in %q at line %d.", line, cfgPath, lineno)
-			}
-			port = line
+			slog.Warn("unable to read code", "error", errRead)
 			continue
 		}
-		keyPart, valPart, found := strings.Cut(line, ":")
-		if !found {
-			log.Fatalf("Invalid config, no separator ':' in %q at line %d.", cfgPath, lineno)
-		}
-		key, errConv := strconv.Atoi(strings.TrimSpace(keyPart))
-		if errConv != nil {
-			log.Fatalf("Wrong integer value %q in %q at line %d.", keyPart, cfgPath, lineno)
+		key, found := cfg.Table()[code]
+		action := key.action
+		if key.kind == actionCommand {
+			action = "!" + action
 		}
-		shortcut, comment, _ := strings.Cut(valPart, "#")
-		table[key] = keyDesc{shortcut: strings.TrimSpace(shortcut), comment: strings.TrimSpace(comment)}
-	}
-	return port, table
-}
-
-func readCode(portFD int) int {
-	buf := make([]byte, 8)
-	size, readErr := unix.Read(portFD, buf)
-	if readErr != nil {
-		log.Fatalf("Unable to read: %v.", readErr)
-	}
-	data := string(buf[:size])
-	value, errConv := strconv.Atoi(strings.TrimSpace(data))
-	if errConv != nil {
-		log.Fatalf("Invalid code value %q: %v.", data, errConv)
-	}
-	return value
-}
-
-func showCodes(portFD int, table map[int]keyDesc) {
-	for {
-		code := readCode(portFD)
-		key, found := table[code]
-		shortcut := key.shortcut
 		if !found {
 			fmt.Printf("%d: ?  # ?\n", code)
 			continue
 		}
 		if key.comment == "" {
-			fmt.Printf("%d: %s\n", code, shortcut)
+			fmt.Printf("%d: %s\n", code, action)
 		} else {
-			fmt.Printf("%d: %s  # %s\n", code, shortcut, key.comment)
+			fmt.Printf("%d: %s  # %s\n", code, action, key.comment)
 		}
 	}
 }
 
-func processCommands(portFD int, table map[int]keyDesc) {
+func processCommands(ctx context.Context, source Source, cfg *Config, backend Backend, notifier *sdnotify.Notifier) {
 	lastTime := time.Now()
-	for {
-		code := readCode(portFD)
+	for ctx.Err() == nil {
+		code, errRead := source.ReadCode(ctx)
+		if errRead != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.Warn("unable to read code", "error", errRead)
+			continue
+		}
 		curTime := time.Now()
 		if curTime.Sub(lastTime) < repeatDelay {
 			continue
 		}
 		lastTime = curTime
-		key, found := table[code]
+		key, found := cfg.Table()[code]
 		if !found {
 			continue
 		}
-		if errExec := exec.Command("xdotool", "key", key.shortcut).Run(); errExec != nil {
-			log.Fatalf("Unable send shortcut %q: %v.", key.shortcut, errExec)
+		switch key.kind {
+		case actionCommand:
+			if errRun := runCommand(ctx, key.action); errRun != nil {
+				slog.Error("unable to run command", "command", key.action, "error", errRun)
+				continue
+			}
+		default:
+			if errSend := backend.Send(key.action); errSend != nil {
+				slog.Error("unable to send shortcut", "shortcut", key.action, "error", errSend)
+				continue
+			}
+		}
+		if errStatus := notifier.Status(fmt.Sprintf("last code: %d, backend: %s", code, cfg.backendName)); errStatus != nil {
+			slog.Warn("unable to send status", "error", errStatus)
 		}
 	}
 }
 
-func notifySystemd() {
-	path := os.Getenv("NOTIFY_SOCKET")
-	if path == "" {
-		return
+func main() {
+	debug := flag.Bool("debug", false, "Enable debug mode.")
+	flag.Parse()
+
+	slog.SetDefault(newLogger())
+
+	cfg, errCfg := newConfig()
+	if errCfg != nil {
+		slog.Error("unable to load config", "error", errCfg)
+		os.Exit(1)
 	}
-	addr := &net.UnixAddr{Name: path, Net: "unixgram"}
-	conn, errDial := net.DialUnix(addr.Net, nil, addr)
-	if errDial != nil {
-		log.Fatalf("Unable open socket %q: %v.", path, errDial)
+
+	source, errSource := newSource(cfg.source)
+	if errSource != nil {
+		slog.Error("unable to create source", "error", errSource)
+		os.Exit(1)
 	}
 	defer func() {
-		if errClose := conn.Close(); errClose != nil {
-			log.Fatalf("Unable close socket %q: %v.", path, errClose)
+		if errClose := source.Close(); errClose != nil {
+			slog.Error("unable to close source", "error", errClose)
 		}
 	}()
-	if _, errSend := conn.Write([]byte("READY=1")); errSend != nil {
-		log.Fatalf("Unable send notify: %v.", errSend)
-	}
-}
-
-func main() {
-	debug := flag.Bool("debug", false, "Enable debug mode.")
-	flag.Parse()
 
-	log.SetFlags(0)
-	log.SetPrefix("FATAL: ")
+	backend, errBackend := newBackend(cfg.backendName)
+	if errBackend != nil {
+		slog.Error("unable to create backend", "error", errBackend)
+		os.Exit(1)
+	}
+	defer func() {
+		if errClose := backend.Close(); errClose != nil {
+			slog.Error("unable to close backend", "error", errClose)
+		}
+	}()
 
-	port, table := loadConfig()
-	portFD := openPort(port)
+	notifier, errNotifier := sdnotify.New()
+	if errNotifier != nil {
+		slog.Error("unable to create notifier", "error", errNotifier)
+		os.Exit(1)
+	}
 	defer func() {
-		if errClose := unix.Close(portFD); errClose != nil {
-			log.Fatalf("Unable close port: %v.", errClose)
+		if errClose := notifier.Close(); errClose != nil {
+			slog.Error("unable to close notifier", "error", errClose)
 		}
 	}()
 
-	notifySystemd()
+	ctx, cancel := context.WithCancel(context.Background())
 
-	signals := make(chan os.Signal)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
-	if *debug {
-		go showCodes(portFD, table)
-	} else {
-		go processCommands(portFD, table)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		cfg.Watch(ctx, notifier)
+	}()
+	go func() {
+		defer wg.Done()
+		notifier.WatchWatchdog(ctx)
+	}()
+
+	if errReady := notifier.Ready(); errReady != nil {
+		slog.Warn("unable to notify ready", "error", errReady)
 	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if *debug {
+			showCodes(ctx, source, cfg)
+		} else {
+			processCommands(ctx, source, cfg, backend, notifier)
+		}
+	}()
+
 	<-signals
+	cancel()
+
+	// The read loops can be stuck in a blocking syscall (e.g. waiting on the
+	// serial port), so don't let a wedged goroutine hold the process open.
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		slog.Warn("shutdown timed out, exiting anyway")
+	}
+
+	if errStopping := notifier.Stopping(); errStopping != nil {
+		slog.Warn("unable to notify stopping", "error", errStopping)
+	}
 }
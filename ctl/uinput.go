@@ -0,0 +1,163 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// uinput ioctl numbers, lifted from <linux/uinput.h>. golang.org/x/sys/unix
+// does not expose them, so they're spelt out the same way it would encode them.
+const (
+	uiSetEvBit   = 0x40045564 // _IOW('U', 100, int)
+	uiSetKeyBit  = 0x40045565 // _IOW('U', 101, int)
+	uiDevCreate  = 0x5501     // _IO('U', 1)
+	uiDevDestroy = 0x5502     // _IO('U', 2)
+
+	uinputMaxNameSize = 80
+	busUSB            = 0x03
+)
+
+type inputID struct {
+	Bustype uint16
+	Vendor  uint16
+	Product uint16
+	Version uint16
+}
+
+type uinputUserDev struct {
+	Name       [uinputMaxNameSize]byte
+	ID         inputID
+	EffectsMax uint32
+	Absmax     [64]int32
+	Absmin     [64]int32
+	Absfuzz    [64]int32
+	Absflat    [64]int32
+}
+
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// uinputBackend drives a virtual /dev/uinput keyboard, it works regardless of
+// the display server (or lack thereof).
+type uinputBackend struct {
+	file *os.File
+}
+
+// newUinputBackend opens /dev/uinput, registers a virtual keyboard able to
+// send every code listed in keysymCodes/modifierKeys and creates the device.
+func newUinputBackend() (Backend, error) {
+	rawFD, errOpen := unix.Open("/dev/uinput", unix.O_WRONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if errOpen != nil {
+		return nil, fmt.Errorf("unable open /dev/uinput: %w", errOpen)
+	}
+	file := os.NewFile(uintptr(rawFD), "/dev/uinput")
+	fd := int(file.Fd())
+
+	if errEvBit := unix.IoctlSetInt(fd, uiSetEvBit, evKey); errEvBit != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("unable set EV_KEY bit: %w", errEvBit)
+	}
+
+	codes := make(map[uint16]struct{})
+	for _, code := range modifierKeys {
+		codes[code] = struct{}{}
+	}
+	for _, code := range keysymCodes {
+		codes[code] = struct{}{}
+	}
+	for code := range codes {
+		if errKeyBit := unix.IoctlSetInt(fd, uiSetKeyBit, int(code)); errKeyBit != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("unable set key bit %d: %w", code, errKeyBit)
+		}
+	}
+
+	dev := uinputUserDev{ID: inputID{Bustype: busUSB, Vendor: 0x1, Product: 0x1, Version: 1}}
+	copy(dev.Name[:], "tvctl virtual keyboard")
+
+	if errWrite := binary.Write(file, binary.LittleEndian, &dev); errWrite != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("unable write uinput device: %w", errWrite)
+	}
+
+	if errCreate := unix.IoctlSetInt(fd, uiDevCreate, 0); errCreate != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("unable create uinput device: %w", errCreate)
+	}
+
+	return &uinputBackend{file: file}, nil
+}
+
+// Send presses the shortcut's modifiers, taps the key, releases everything in
+// reverse order and syncs, exactly the way a real keyboard would report it.
+func (b *uinputBackend) Send(shortcut string) error {
+	keys, errParse := parseShortcut(shortcut)
+	if errParse != nil {
+		return errParse
+	}
+	for _, code := range keys.modifiers {
+		if errEmit := b.emitKey(code, 1); errEmit != nil {
+			return errEmit
+		}
+	}
+	if errEmit := b.emitKey(keys.key, 1); errEmit != nil {
+		return errEmit
+	}
+	if errEmit := b.emitKey(keys.key, 0); errEmit != nil {
+		return errEmit
+	}
+	for i := len(keys.modifiers) - 1; i >= 0; i-- {
+		if errEmit := b.emitKey(keys.modifiers[i], 0); errEmit != nil {
+			return errEmit
+		}
+	}
+	return b.sync()
+}
+
+func (b *uinputBackend) emitKey(code uint16, value int32) error {
+	return b.write(inputEvent{Type: evKey, Code: code, Value: value})
+}
+
+func (b *uinputBackend) sync() error {
+	return b.write(inputEvent{Type: evSyn, Code: synReport, Value: 0})
+}
+
+func (b *uinputBackend) write(event inputEvent) error {
+	return binary.Write(b.file, binary.LittleEndian, &event)
+}
+
+// Close destroys the virtual device and closes the file descriptor, it must
+// run on SIGTERM or the device lingers until the process exits anyway.
+func (b *uinputBackend) Close() error {
+	fd := int(b.file.Fd())
+	if errDestroy := unix.IoctlSetInt(fd, uiDevDestroy, 0); errDestroy != nil {
+		_ = b.file.Close()
+		return fmt.Errorf("unable destroy uinput device: %w", errDestroy)
+	}
+	return b.file.Close()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+func openPipe() (*os.File, *os.File, error) {
+	return os.Pipe()
+}
+
+func readEvents(r *os.File) ([]inputEvent, error) {
+	defer func() { _ = r.Close() }()
+	var events []inputEvent
+	for {
+		var event inputEvent
+		if err := binary.Read(r, binary.LittleEndian, &event); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func TestParseShortcutModifiersAndKey(t *testing.T) {
+	got, err := parseShortcut("ctrl+alt+t")
+	if err != nil {
+		t.Fatalf("parseShortcut returned error: %v", err)
+	}
+	want := []uint16{keyLeftCtrl, keyLeftAlt}
+	if len(got.modifiers) != len(want) {
+		t.Fatalf("modifiers = %v, want %v", got.modifiers, want)
+	}
+	for i, code := range want {
+		if got.modifiers[i] != code {
+			t.Errorf("modifiers[%d] = %d, want %d", i, got.modifiers[i], code)
+		}
+	}
+	if got.key != keyT {
+		t.Errorf("key = %d, want %d", got.key, keyT)
+	}
+}
+
+func TestParseShortcutXF86Key(t *testing.T) {
+	got, err := parseShortcut("XF86AudioRaiseVolume")
+	if err != nil {
+		t.Fatalf("parseShortcut returned error: %v", err)
+	}
+	if len(got.modifiers) != 0 {
+		t.Errorf("modifiers = %v, want none", got.modifiers)
+	}
+	if got.key != keyVolumeUp {
+		t.Errorf("key = %d, want %d", got.key, keyVolumeUp)
+	}
+}
+
+func TestParseShortcutUnknownKey(t *testing.T) {
+	if _, err := parseShortcut("ctrl+bogus"); err == nil {
+		t.Fatal("expected an error for an unknown key")
+	}
+}
+
+// newFakeUinputBackend pipes the writes a uinputBackend would otherwise send to a real
+// /dev/uinput fd, so Send()'s event sequence can be inspected without root.
+func newFakeUinputBackend(t *testing.T) (*uinputBackend, func() []inputEvent) {
+	t.Helper()
+	r, w, errPipe := openPipe()
+	if errPipe != nil {
+		t.Fatalf("unable open pipe: %v", errPipe)
+	}
+	b := &uinputBackend{file: w}
+	return b, func() []inputEvent {
+		_ = w.Close()
+		events, errRead := readEvents(r)
+		if errRead != nil {
+			t.Fatalf("unable read events: %v", errRead)
+		}
+		return events
+	}
+}
+
+func TestUinputBackendSendOrder(t *testing.T) {
+	b, drain := newFakeUinputBackend(t)
+	if err := b.Send("ctrl+alt+t"); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+	events := drain()
+
+	wantCodes := []struct {
+		code  uint16
+		value int32
+	}{
+		{keyLeftCtrl, 1},
+		{keyLeftAlt, 1},
+		{keyT, 1},
+		{keyT, 0},
+		{keyLeftAlt, 0},
+		{keyLeftCtrl, 0},
+	}
+	if len(events) != len(wantCodes)+1 { // +1 for the trailing EV_SYN.
+		t.Fatalf("got %d events, want %d", len(events), len(wantCodes)+1)
+	}
+	for i, want := range wantCodes {
+		if events[i].Code != want.code || events[i].Value != want.value {
+			t.Errorf("event[%d] = {code: %d, value: %d}, want {code: %d, value: %d}", i, events[i].Code, events[i].Value, want.code, want.value)
+		}
+	}
+	last := events[len(events)-1]
+	if last.Type != evSyn || last.Code != synReport {
+		t.Errorf("last event = %+v, want an EV_SYN/SYN_REPORT", last)
+	}
+}
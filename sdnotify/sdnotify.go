@@ -0,0 +1,117 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package sdnotify talks the sd_notify(3) protocol to systemd: readiness,
+// status, reload/stop transitions and the watchdog keep-alive. Every method
+// is a no-op on a nil *Notifier, so callers don't need to special-case
+// running outside of systemd.
+package sdnotify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notifier holds the NOTIFY_SOCKET connection and the watchdog interval
+// systemd asked for, if any.
+type Notifier struct {
+	conn             *net.UnixConn
+	watchdogInterval time.Duration
+}
+
+// New dials NOTIFY_SOCKET and reads WATCHDOG_USEC. It returns a nil
+// *Notifier (and a nil error) when NOTIFY_SOCKET isn't set, i.e. the process
+// isn't running under systemd.
+func New() (*Notifier, error) {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil, nil
+	}
+	addr := &net.UnixAddr{Name: path, Net: "unixgram"}
+	conn, errDial := net.DialUnix(addr.Net, nil, addr)
+	if errDial != nil {
+		return nil, fmt.Errorf("unable open socket %q: %w", path, errDial)
+	}
+
+	n := &Notifier{conn: conn}
+	if usec, errConv := strconv.Atoi(os.Getenv("WATCHDOG_USEC")); errConv == nil && usec > 0 {
+		n.watchdogInterval = time.Duration(usec) * time.Microsecond
+	}
+	return n, nil
+}
+
+func (n *Notifier) send(state string) error {
+	if n == nil {
+		return nil
+	}
+	if _, errSend := n.conn.Write([]byte(state)); errSend != nil {
+		return fmt.Errorf("unable send notify: %w", errSend)
+	}
+	return nil
+}
+
+// Ready reports READY=1, the daemon has finished starting up.
+func (n *Notifier) Ready() error {
+	return n.send("READY=1")
+}
+
+// Reloading reports RELOADING=1, it must be followed by a Ready once the
+// reload is done.
+func (n *Notifier) Reloading() error {
+	return n.send("RELOADING=1")
+}
+
+// Stopping reports STOPPING=1, it must be sent before the daemon exits.
+func (n *Notifier) Stopping() error {
+	return n.send("STOPPING=1")
+}
+
+// Status reports a free-form STATUS= line, e.g. the last code seen and the
+// backend in use.
+func (n *Notifier) Status(msg string) error {
+	return n.send("STATUS=" + msg)
+}
+
+// WatchWatchdog sends WATCHDOG=1 at half of WATCHDOG_USEC until ctx is
+// cancelled. It returns immediately if systemd didn't ask for a watchdog.
+func (n *Notifier) WatchWatchdog(ctx context.Context) {
+	if n == nil || n.watchdogInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(n.watchdogInterval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = n.send("WATCHDOG=1")
+		}
+	}
+}
+
+// Close closes the NOTIFY_SOCKET connection.
+func (n *Notifier) Close() error {
+	if n == nil {
+		return nil
+	}
+	return n.conn.Close()
+}
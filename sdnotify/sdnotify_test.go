@@ -0,0 +1,140 @@
+/*
+	tvctl - A daemon which receives key codes from Arduino and emulates keyboard actions according to a config file.
+
+	Copyright (C) 2022~2023 Vadim Kuznetsov <vimusov@gmail.com>
+
+	This program is free software: you can redistribute it and/or modify
+	it under the terms of the GNU General Public License as published by
+	the Free Software Foundation, either version 3 of the License, or
+	(at your option) any later version.
+	This program is distributed in the hope that it will be useful,
+	but WITHOUT ANY WARRANTY; without even the implied warranty of
+	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+	GNU General Public License for more details.
+	You should have received a copy of the GNU General Public License
+	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package sdnotify
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// listenAbstract opens an abstract-namespace unixgram socket (the form
+// systemd itself uses for NOTIFY_SOCKET) unique to this test run.
+func listenAbstract(t *testing.T, suffix string) (*net.UnixConn, string) {
+	t.Helper()
+	addr := fmt.Sprintf("@tvctl-test-%s-%d", suffix, os.Getpid())
+	listener, errListen := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if errListen != nil {
+		t.Fatalf("unable to listen on %q: %v", addr, errListen)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	return listener, addr
+}
+
+func TestNewWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	n, errNew := New()
+	if errNew != nil {
+		t.Fatalf("New returned error: %v", errNew)
+	}
+	if n != nil {
+		t.Fatalf("expected a nil Notifier, got %+v", n)
+	}
+}
+
+func TestNewAbstractSocket(t *testing.T) {
+	listener, addr := listenAbstract(t, "ready")
+	t.Setenv("NOTIFY_SOCKET", addr)
+	t.Setenv("WATCHDOG_USEC", "")
+
+	n, errNew := New()
+	if errNew != nil {
+		t.Fatalf("New returned error: %v", errNew)
+	}
+	defer func() { _ = n.Close() }()
+
+	if n.watchdogInterval != 0 {
+		t.Errorf("watchdogInterval = %v, want 0 (WATCHDOG_USEC unset)", n.watchdogInterval)
+	}
+
+	if errReady := n.Ready(); errReady != nil {
+		t.Fatalf("Ready returned error: %v", errReady)
+	}
+
+	_ = listener.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 64)
+	size, errRead := listener.Read(buf)
+	if errRead != nil {
+		t.Fatalf("unable to read notification: %v", errRead)
+	}
+	if got := string(buf[:size]); got != "READY=1" {
+		t.Errorf("got message %q, want %q", got, "READY=1")
+	}
+}
+
+func TestNewParsesWatchdogUsec(t *testing.T) {
+	_, addr := listenAbstract(t, "watchdog-usec")
+	t.Setenv("NOTIFY_SOCKET", addr)
+	t.Setenv("WATCHDOG_USEC", "2000000")
+
+	n, errNew := New()
+	if errNew != nil {
+		t.Fatalf("New returned error: %v", errNew)
+	}
+	defer func() { _ = n.Close() }()
+
+	if want := 2 * time.Second; n.watchdogInterval != want {
+		t.Errorf("watchdogInterval = %v, want %v", n.watchdogInterval, want)
+	}
+}
+
+func TestWatchWatchdogSendsPing(t *testing.T) {
+	listener, addr := listenAbstract(t, "watchdog-ping")
+	t.Setenv("NOTIFY_SOCKET", addr)
+	t.Setenv("WATCHDOG_USEC", "20000")
+
+	n, errNew := New()
+	if errNew != nil {
+		t.Fatalf("New returned error: %v", errNew)
+	}
+	defer func() { _ = n.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		n.WatchWatchdog(ctx)
+		close(done)
+	}()
+
+	_ = listener.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	buf := make([]byte, 64)
+	size, errRead := listener.Read(buf)
+	if errRead != nil {
+		t.Fatalf("expected a watchdog ping, got error: %v", errRead)
+	}
+	if got := string(buf[:size]); got != "WATCHDOG=1" {
+		t.Errorf("got message %q, want %q", got, "WATCHDOG=1")
+	}
+
+	<-done
+}
+
+func TestNilNotifierMethodsAreNoops(t *testing.T) {
+	var n *Notifier
+	if errReady := n.Ready(); errReady != nil {
+		t.Errorf("Ready on nil Notifier returned error: %v", errReady)
+	}
+	n.WatchWatchdog(context.Background())
+	if errClose := n.Close(); errClose != nil {
+		t.Errorf("Close on nil Notifier returned error: %v", errClose)
+	}
+}